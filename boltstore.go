@@ -0,0 +1,101 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+
+	bolt "go.etcd.io/bbolt"
+)
+
+var (
+	boltPointsBucket = []byte("points")
+	boltHashesBucket = []byte("hashes")
+)
+
+// boltReceiptRecord is what gets persisted per receipt id in the points bucket.
+type boltReceiptRecord struct {
+	Points int `json:"points"`
+}
+
+// BoltStore is a single-node, embedded persistent Store backed by BoltDB.
+type BoltStore struct {
+	db *bolt.DB
+}
+
+// NewBoltStore opens (creating if necessary) a bolt database at path and
+// ensures its buckets exist.
+func NewBoltStore(path string) (*BoltStore, error) {
+	db, err := bolt.Open(path, 0600, nil)
+	if err != nil {
+		return nil, fmt.Errorf("opening bolt store at %s: %w", path, err)
+	}
+
+	err = db.Update(func(tx *bolt.Tx) error {
+		if _, err := tx.CreateBucketIfNotExists(boltPointsBucket); err != nil {
+			return err
+		}
+		_, err := tx.CreateBucketIfNotExists(boltHashesBucket)
+		return err
+	})
+	if err != nil {
+		db.Close()
+		return nil, fmt.Errorf("initializing bolt buckets: %w", err)
+	}
+
+	return &BoltStore{db: db}, nil
+}
+
+func (s *BoltStore) SaveReceipt(id string, receipt Receipt, points int) error {
+	hash, err := hashReceipt(receipt)
+	if err != nil {
+		return err
+	}
+
+	record, err := json.Marshal(boltReceiptRecord{Points: points})
+	if err != nil {
+		return err
+	}
+
+	return s.db.Update(func(tx *bolt.Tx) error {
+		if err := tx.Bucket(boltPointsBucket).Put([]byte(id), record); err != nil {
+			return err
+		}
+		return tx.Bucket(boltHashesBucket).Put([]byte(hash), []byte(id))
+	})
+}
+
+func (s *BoltStore) GetPoints(id string) (int, bool, error) {
+	var record boltReceiptRecord
+	found := false
+
+	err := s.db.View(func(tx *bolt.Tx) error {
+		data := tx.Bucket(boltPointsBucket).Get([]byte(id))
+		if data == nil {
+			return nil
+		}
+		found = true
+		return json.Unmarshal(data, &record)
+	})
+	if err != nil {
+		return 0, false, err
+	}
+	return record.Points, found, nil
+}
+
+func (s *BoltStore) LookupByHash(hash string) (string, bool) {
+	var id string
+
+	s.db.View(func(tx *bolt.Tx) error {
+		data := tx.Bucket(boltHashesBucket).Get([]byte(hash))
+		if data != nil {
+			id = string(data)
+		}
+		return nil
+	})
+	return id, id != ""
+}
+
+// Close releases the underlying bolt database file.
+func (s *BoltStore) Close() error {
+	return s.db.Close()
+}
@@ -0,0 +1,123 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"sync"
+	"time"
+)
+
+// idempotencyTTL is how long an Idempotency-Key is remembered before it can
+// be reused for a different receipt.
+const idempotencyTTL = 24 * time.Hour
+
+// idempotencyEntry records which receipt an Idempotency-Key resolved to, and
+// the content hash it was bound to, so a key reused with a different body
+// can be rejected.
+type idempotencyEntry struct {
+	id        string
+	bodyHash  string
+	expiresAt time.Time
+}
+
+var (
+	idempotencyMu   sync.Mutex
+	idempotencyKeys = make(map[string]idempotencyEntry)
+	receiptHashes   = make(map[string]string) // content hash -> receipt id
+)
+
+// hashReceipt returns the SHA-256 hash of the receipt's canonical JSON
+// encoding, used to detect content-duplicate submissions.
+func hashReceipt(receipt Receipt) (string, error) {
+	data, err := json.Marshal(receipt)
+	if err != nil {
+		return "", err
+	}
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:]), nil
+}
+
+// resolveReceiptID looks up an existing receipt for the given idempotency
+// key and/or content hash, minting a new id via newID only if neither
+// matches. It returns conflict=true if key was already bound to a different
+// body, and replay=true if an existing id was returned instead of a new one.
+// The whole check-then-insert happens under a single lock so concurrent
+// submissions of the same receipt only ever mint one id. store is consulted
+// as a fallback for the content-hash check so dedup survives a restart even
+// though the in-memory receiptHashes map doesn't.
+func resolveReceiptID(key, hash string, newID func() string, store Store) (id string, replay bool, conflict bool) {
+	idempotencyMu.Lock()
+	defer idempotencyMu.Unlock()
+
+	if key != "" {
+		if entry, ok := idempotencyKeys[key]; ok && time.Now().Before(entry.expiresAt) {
+			if entry.bodyHash != hash {
+				return "", false, true
+			}
+			return entry.id, true, false
+		}
+	}
+
+	existingID, ok := receiptHashes[hash]
+	if !ok {
+		existingID, ok = store.LookupByHash(hash)
+	}
+	if ok {
+		receiptHashes[hash] = existingID
+		if key != "" {
+			idempotencyKeys[key] = idempotencyEntry{id: existingID, bodyHash: hash, expiresAt: time.Now().Add(idempotencyTTL)}
+		}
+		return existingID, true, false
+	}
+
+	id = newID()
+	receiptHashes[hash] = id
+	if key != "" {
+		idempotencyKeys[key] = idempotencyEntry{id: id, bodyHash: hash, expiresAt: time.Now().Add(idempotencyTTL)}
+	}
+	return id, false, false
+}
+
+// releaseReceiptID undoes the reservation resolveReceiptID made for id when
+// the caller failed to actually persist the receipt (e.g. store.SaveReceipt
+// returned an error). Without this, a failed save would leave the hash/key
+// bindings pointing at an id the store never recorded, permanently turning
+// every retry into a replay of a receipt that 404s on lookup.
+func releaseReceiptID(key, hash, id string) {
+	idempotencyMu.Lock()
+	defer idempotencyMu.Unlock()
+
+	if existing, ok := receiptHashes[hash]; ok && existing == id {
+		delete(receiptHashes, hash)
+	}
+	if key != "" {
+		if entry, ok := idempotencyKeys[key]; ok && entry.id == id {
+			delete(idempotencyKeys, key)
+		}
+	}
+}
+
+// evictExpiredIdempotencyKeys removes Idempotency-Key entries past their TTL.
+// The content-hash map is left alone since it has no TTL of its own.
+func evictExpiredIdempotencyKeys() {
+	idempotencyMu.Lock()
+	defer idempotencyMu.Unlock()
+	now := time.Now()
+	for key, entry := range idempotencyKeys {
+		if now.After(entry.expiresAt) {
+			delete(idempotencyKeys, key)
+		}
+	}
+}
+
+// startIdempotencyEviction periodically sweeps expired Idempotency-Key
+// entries so the map doesn't grow unbounded in a long-running process.
+func startIdempotencyEviction(interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	go func() {
+		for range ticker.C {
+			evictExpiredIdempotencyKeys()
+		}
+	}()
+}
@@ -0,0 +1,198 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+)
+
+// flakyStore wraps a Store and fails the next SaveReceipt call exactly once,
+// to exercise the rollback path when persistence fails.
+type flakyStore struct {
+	Store
+	failNext bool
+}
+
+func (f *flakyStore) SaveReceipt(id string, receipt Receipt, points int) error {
+	if f.failNext {
+		f.failNext = false
+		return errors.New("simulated save failure")
+	}
+	return f.Store.SaveReceipt(id, receipt, points)
+}
+
+func sampleReceiptForIdempotency() Receipt {
+	return Receipt{
+		Retailer:     "Target",
+		PurchaseDate: "2022-01-01",
+		PurchaseTime: "13:01",
+		Items: []Item{
+			{ShortDescription: "Mountain Dew 12PK", Price: "6.49"},
+		},
+		Total: "6.49",
+	}
+}
+
+// Verifies that replaying the same Idempotency-Key with the same body
+// returns the original id and sets the replay header.
+func TestProcessReceiptsIdempotencyKeyReplay(t *testing.T) {
+	body, err := json.Marshal(sampleReceiptForIdempotency())
+	if err != nil {
+		t.Fatalf("Failed to marshal receipt: %v", err)
+	}
+
+	firstReq := httptest.NewRequest(http.MethodPost, "/receipts/process", bytes.NewReader(body))
+	firstReq.Header.Set("Idempotency-Key", "key-1")
+	firstW := httptest.NewRecorder()
+	processReceiptsHandler(firstW, firstReq)
+
+	var first map[string]string
+	if err := json.NewDecoder(firstW.Result().Body).Decode(&first); err != nil {
+		t.Fatalf("Failed to decode first response: %v", err)
+	}
+
+	secondReq := httptest.NewRequest(http.MethodPost, "/receipts/process", bytes.NewReader(body))
+	secondReq.Header.Set("Idempotency-Key", "key-1")
+	secondW := httptest.NewRecorder()
+	processReceiptsHandler(secondW, secondReq)
+
+	resp := secondW.Result()
+	if resp.Header.Get("X-Idempotent-Replay") != "true" {
+		t.Errorf("Expected X-Idempotent-Replay header on replay")
+	}
+
+	var second map[string]string
+	if err := json.NewDecoder(resp.Body).Decode(&second); err != nil {
+		t.Fatalf("Failed to decode second response: %v", err)
+	}
+	if second["id"] != first["id"] {
+		t.Errorf("Expected replay to return the same id; got %v and %v", first["id"], second["id"])
+	}
+}
+
+// Verifies that reusing an Idempotency-Key with a different body is rejected.
+func TestProcessReceiptsIdempotencyKeyConflict(t *testing.T) {
+	body, err := json.Marshal(sampleReceiptForIdempotency())
+	if err != nil {
+		t.Fatalf("Failed to marshal receipt: %v", err)
+	}
+
+	req := httptest.NewRequest(http.MethodPost, "/receipts/process", bytes.NewReader(body))
+	req.Header.Set("Idempotency-Key", "key-2")
+	w := httptest.NewRecorder()
+	processReceiptsHandler(w, req)
+
+	differentReceipt := sampleReceiptForIdempotency()
+	differentReceipt.Total = "99.99"
+	differentBody, err := json.Marshal(differentReceipt)
+	if err != nil {
+		t.Fatalf("Failed to marshal receipt: %v", err)
+	}
+
+	conflictReq := httptest.NewRequest(http.MethodPost, "/receipts/process", bytes.NewReader(differentBody))
+	conflictReq.Header.Set("Idempotency-Key", "key-2")
+	conflictW := httptest.NewRecorder()
+	processReceiptsHandler(conflictW, conflictReq)
+
+	resp := conflictW.Result()
+	if resp.StatusCode != http.StatusConflict {
+		t.Fatalf("Expected status Conflict; got %v", resp.Status)
+	}
+}
+
+// Verifies that identical receipts submitted concurrently without an
+// Idempotency-Key are still deduplicated by content hash, minting exactly
+// one UUID.
+func TestProcessReceiptsConcurrentDuplicatesMintOneID(t *testing.T) {
+	receipt := sampleReceiptForIdempotency()
+	receipt.Retailer = "ConcurrentDedupeTarget"
+	body, err := json.Marshal(receipt)
+	if err != nil {
+		t.Fatalf("Failed to marshal receipt: %v", err)
+	}
+
+	const numRequests = 10
+	ids := make([]string, numRequests)
+	var wg sync.WaitGroup
+	wg.Add(numRequests)
+
+	for i := 0; i < numRequests; i++ {
+		i := i
+		go func() {
+			defer wg.Done()
+			req := httptest.NewRequest(http.MethodPost, "/receipts/process", bytes.NewReader(body))
+			w := httptest.NewRecorder()
+			processReceiptsHandler(w, req)
+
+			var result map[string]string
+			if err := json.NewDecoder(w.Result().Body).Decode(&result); err != nil {
+				t.Errorf("Failed to decode response: %v", err)
+				return
+			}
+			ids[i] = result["id"]
+		}()
+	}
+	wg.Wait()
+
+	for i := 1; i < numRequests; i++ {
+		if ids[i] != ids[0] {
+			t.Errorf("Expected every concurrent duplicate to resolve to id %q; got %q", ids[0], ids[i])
+		}
+	}
+}
+
+// Verifies that a failed store.SaveReceipt rolls back the hash/key
+// reservation, so a retry of the same request mints a fresh id instead of
+// replaying an id the store never actually persisted.
+func TestProcessReceiptsRollsBackReservationOnSaveFailure(t *testing.T) {
+	original := store
+	defer func() { store = original }()
+
+	flaky := &flakyStore{Store: NewMemoryStore(), failNext: true}
+	store = flaky
+
+	receipt := sampleReceiptForIdempotency()
+	receipt.Retailer = "RollbackTarget"
+	body, err := json.Marshal(receipt)
+	if err != nil {
+		t.Fatalf("Failed to marshal receipt: %v", err)
+	}
+
+	failReq := httptest.NewRequest(http.MethodPost, "/receipts/process", bytes.NewReader(body))
+	failReq.Header.Set("Idempotency-Key", "rollback-key")
+	failW := httptest.NewRecorder()
+	processReceiptsHandler(failW, failReq)
+
+	if failW.Result().StatusCode != http.StatusInternalServerError {
+		t.Fatalf("Expected the first save to fail with 500; got %v", failW.Result().Status)
+	}
+
+	retryReq := httptest.NewRequest(http.MethodPost, "/receipts/process", bytes.NewReader(body))
+	retryReq.Header.Set("Idempotency-Key", "rollback-key")
+	retryW := httptest.NewRecorder()
+	processReceiptsHandler(retryW, retryReq)
+
+	resp := retryW.Result()
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("Expected the retry to succeed; got %v", resp.Status)
+	}
+	if resp.Header.Get("X-Idempotent-Replay") == "true" {
+		t.Fatalf("Expected the retry to mint a fresh id instead of replaying the failed one")
+	}
+
+	var result map[string]string
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		t.Fatalf("Failed to decode retry response: %v", err)
+	}
+
+	pointsReq := httptest.NewRequest(http.MethodGet, "/receipts/"+result["id"]+"/points", nil)
+	pointsW := httptest.NewRecorder()
+	getPointsHandler(pointsW, pointsReq)
+	if pointsW.Result().StatusCode != http.StatusOK {
+		t.Fatalf("Expected GET points to succeed for the retried receipt; got %v", pointsW.Result().Status)
+	}
+}
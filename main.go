@@ -2,22 +2,23 @@ package main
 
 import (
 	"encoding/json"
+	"flag"
 	"fmt"
 	"github.com/google/uuid"
-	"math"
+	"log/slog"
 	"net/http"
-	"regexp"
-	"strconv"
 	"strings"
 	"sync"
 	"time"
 )
 
-// In-memory storage for receipts and their calculated points
+// store persists receipts and their points; see openStore for the available
+// backends. breakdowns holds the per-rule trace for the explain/trace
+// endpoints and, unlike store, never survives a restart.
 var (
-	receipts = make(map[string]Receipt)
-	points   = make(map[string]int)
-	mu       sync.Mutex // Mutex to handle concurrent access to the maps
+	store      Store      = NewMemoryStore()
+	breakdowns            = make(map[string][]RuleResult)
+	mu         sync.Mutex // Mutex to handle concurrent access to breakdowns
 )
 
 // Item represents a single item in the receipt
@@ -35,18 +36,80 @@ type Receipt struct {
 	Total        string `json:"total"`
 }
 
-// ReceiptPoints represents the points awarded from a receipt
+// ReceiptPoints represents the points awarded from a receipt. Rules is only
+// populated when the caller asks for an explanation.
 type ReceiptPoints struct {
-	Points int `json:"points"`
+	Points int          `json:"points"`
+	Rules  []RuleResult `json:"rules,omitempty"`
 }
 
 // main function to start the HTTP server and sets up the endpoints
 func main() {
-	fmt.Println("Starting server on port 8081...")
-	http.HandleFunc("/test", testHandler)
-	http.HandleFunc("/receipts/process", processReceiptsHandler)
-	http.HandleFunc("/receipts/", getPointsHandler)
-	http.ListenAndServe(":8081", nil)
+	rulesDir := flag.String("rules-dir", "", "directory of .so rule plugins to load at startup")
+	rulesConfig := flag.String("rules-config", "", "path to a YAML file enabling/reordering rules")
+	storeKind := flag.String("store", "memory", "receipt store backend: memory, bolt, or postgres")
+	storePath := flag.String("store-path", "", "bolt database file path, when --store=bolt")
+	storeDSN := flag.String("store-dsn", "", "postgres connection string, when --store=postgres")
+	addr := flag.String("addr", ":8081", "address for the HTTP(S) server to listen on")
+	tlsCert := flag.String("tls-cert", "", "TLS certificate file; enables HTTPS when set with --tls-key")
+	tlsKey := flag.String("tls-key", "", "TLS private key file; enables HTTPS when set with --tls-cert")
+	clientCA := flag.String("client-ca", "", "CA bundle for verifying client certificates; enables mutual TLS")
+	flag.Parse()
+
+	openedStore, err := openStore(StoreOptions{Kind: *storeKind, BoltPath: *storePath, PostgresDSN: *storeDSN})
+	if err != nil {
+		fmt.Printf("failed to open %s store: %v\n", *storeKind, err)
+		return
+	}
+	store = openedStore
+
+	if *rulesDir != "" {
+		if err := loadRulePlugins(*rulesDir); err != nil {
+			fmt.Printf("failed to load rule plugins from %s: %v\n", *rulesDir, err)
+		}
+	}
+	if *rulesConfig != "" {
+		cfg, err := loadRulesConfig(*rulesConfig)
+		if err != nil {
+			fmt.Printf("failed to load rules config from %s: %v\n", *rulesConfig, err)
+		} else {
+			configureRules(cfg)
+		}
+	}
+
+	startIdempotencyEviction(time.Hour)
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/test", testHandler)
+	mux.HandleFunc("/healthz", healthzHandler)
+	mux.HandleFunc("/readyz", readyzHandler)
+	mux.HandleFunc("/receipts/process", processReceiptsHandler)
+	mux.HandleFunc("/receipts/", receiptsHandler)
+
+	serverOpts := ServerOptions{Addr: *addr, TLSCert: *tlsCert, TLSKey: *tlsKey, ClientCA: *clientCA}
+	server, err := buildServer(mux, serverOpts)
+	if err != nil {
+		fmt.Printf("failed to configure server: %v\n", err)
+		return
+	}
+
+	fmt.Printf("Starting server on %s...\n", *addr)
+	if err := serve(server, serverOpts); err != nil {
+		fmt.Printf("server error: %v\n", err)
+	}
+}
+
+// receiptsHandler dispatches GET /receipts/{id}/points and
+// GET /receipts/{id}/trace requests to their respective handlers.
+func receiptsHandler(w http.ResponseWriter, r *http.Request) {
+	switch {
+	case strings.HasSuffix(r.URL.Path, "/points"):
+		getPointsHandler(w, r)
+	case strings.HasSuffix(r.URL.Path, "/trace"):
+		getTraceHandler(w, r)
+	default:
+		http.NotFound(w, r)
+	}
 }
 
 // testHandler is a simple endpoint to verify that the server is running
@@ -56,7 +119,7 @@ func testHandler(w http.ResponseWriter, r *http.Request) {
 
 // processReceiptsHandler processes a receipt and calculates its points
 func processReceiptsHandler(w http.ResponseWriter, r *http.Request) {
-	fmt.Println("Processing receipt...")
+	slog.Debug("processing receipt")
 	var receipt Receipt
 
 	// Decode the JSON request body into a Receipt struct
@@ -72,11 +135,35 @@ func processReceiptsHandler(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	// Generate a unique ID for the receipt and calculate points for it
-	id := uuid.New().String()
+	// Honor Idempotency-Key and content-duplicate detection before minting a
+	// new id, so retries and concurrent duplicate submissions reuse the same
+	// receipt instead of creating a new one each time.
+	hash, err := hashReceipt(receipt)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	idempotencyKey := r.Header.Get("Idempotency-Key")
+
+	id, replay, conflict := resolveReceiptID(idempotencyKey, hash, func() string { return uuid.New().String() }, store)
+	if conflict {
+		http.Error(w, "Idempotency-Key was already used with a different request body", http.StatusConflict)
+		return
+	}
+	if replay {
+		w.Header().Set("X-Idempotent-Replay", "true")
+		json.NewEncoder(w).Encode(map[string]string{"id": id})
+		return
+	}
+
+	total, breakdown := runRules(receipt)
+	if err := store.SaveReceipt(id, receipt, total); err != nil {
+		releaseReceiptID(idempotencyKey, hash, id)
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
 	mu.Lock()
-	receipts[id] = receipt
-	points[id] = calculatePoints(receipt)
+	breakdowns[id] = breakdown
 	mu.Unlock()
 
 	response := map[string]string{"id": id}
@@ -87,89 +174,63 @@ func processReceiptsHandler(w http.ResponseWriter, r *http.Request) {
 
 // getPointsHandler retrieves the points for a given receipt
 func getPointsHandler(w http.ResponseWriter, r *http.Request) {
-	fmt.Println("Getting points for receipt...")
+	slog.Debug("getting points for receipt")
 
 	// Extract the receipt ID from the URL
 	id := r.URL.Path[len("/receipts/") : len(r.URL.Path)-len("/points")]
 
-	mu.Lock()
-	pointsValue, ok := points[id]
-	mu.Unlock()
-
+	pointsValue, ok, err := store.GetPoints(id)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
 	if !ok {
 		http.Error(w, "receipt not found", http.StatusNotFound)
 		return
 	}
 
+	mu.Lock()
+	breakdown := breakdowns[id]
+	mu.Unlock()
+
 	response := ReceiptPoints{Points: pointsValue}
+	if r.URL.Query().Get("explain") == "1" {
+		response.Rules = breakdown
+	}
 	json.NewEncoder(w).Encode(response)
 }
 
-// calculatePoints calculates the points for a given receipt based on specific rules
-func calculatePoints(receipt Receipt) int {
-	points := 0
-
-	// Rule 1: One point for every alphanumeric character in the retailer name.
-	alphanumeric := regexp.MustCompile("[a-zA-Z0-9]")
-	points += len(alphanumeric.FindAllString(receipt.Retailer, -1))
-	fmt.Printf("Rule 1: points = %d\n", points)
-
-	// Rule 2: 50 points if the total is a round dollar amount with no cents.
-	if total, err := strconv.ParseFloat(receipt.Total, 64); err == nil {
-		if math.Mod(total, 1.0) == 0 {
-			points += 50
-			fmt.Printf("Rule 2: points = %d\n", points)
-		}
-	}
+// TraceResponse is the full rule-execution trace for a scored receipt.
+type TraceResponse struct {
+	Total int          `json:"total"`
+	Steps []RuleResult `json:"steps"`
+}
 
-	// Rule 3: 25 points if the total is a multiple of 0.25.
-	if total, err := strconv.ParseFloat(receipt.Total, 64); err == nil {
-		if math.Mod(total, 0.25) == 0 {
-			points += 25
-			fmt.Printf("Rule 3: points = %d\n", points)
-		}
-	}
+// getTraceHandler returns the per-rule trace recorded when a receipt was
+// processed.
+func getTraceHandler(w http.ResponseWriter, r *http.Request) {
+	id := r.URL.Path[len("/receipts/") : len(r.URL.Path)-len("/trace")]
 
-	// Rule 4: 5 points for every two items on the receipt.
-	points += (len(receipt.Items) / 2) * 5
-	fmt.Printf("Rule 4: points = %d\n", points)
-
-	// Rule 5: Points for item description length.
-	for _, item := range receipt.Items {
-
-		originalLength := len(item.ShortDescription)
-		trimmed := strings.TrimSpace(item.ShortDescription)
-		alphanumeric := regexp.MustCompile("[a-zA-Z0-9]")
-		cleaned := alphanumeric.FindAllString(trimmed, -1)
-		cleanedLength := len(strings.Join(cleaned, ""))
-		fmt.Printf("Item: %s, Original Length: %d, Trimmed: %s, Cleaned Length: %d\n",
-			item.ShortDescription, originalLength, trimmed, cleanedLength)
-
-		if cleanedLength%3 == 0 {
-			if price, err := strconv.ParseFloat(item.Price, 64); err == nil {
-				additionalPoints := int(math.Ceil(price * 0.2))
-				fmt.Printf("Price: %f, Additional Points: %d\n", price, additionalPoints)
-				points += additionalPoints
-				fmt.Printf("Rule 5: points = %d\n", points)
-			}
-		}
+	pointsValue, ok, err := store.GetPoints(id)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
 	}
-
-	// Rule 6: 6 points if the day in the purchase date is odd.
-	if date, err := time.Parse("2006-01-02", receipt.PurchaseDate); err == nil {
-		if date.Day()%2 != 0 {
-			points += 6
-			fmt.Printf("Rule 6: points = %d\n", points)
-		}
+	if !ok {
+		http.Error(w, "receipt not found", http.StatusNotFound)
+		return
 	}
 
-	// Rule 7: 10 points if the time of purchase is after 2:00pm and before 4:00pm.
-	if purchaseTime, err := time.Parse("15:04", receipt.PurchaseTime); err == nil {
-		if purchaseTime.Hour() >= 14 && purchaseTime.Hour() < 16 {
-			points += 10
-			fmt.Printf("Rule 7: points = %d\n", points)
-		}
-	}
+	mu.Lock()
+	breakdown := breakdowns[id]
+	mu.Unlock()
 
-	return points
+	json.NewEncoder(w).Encode(TraceResponse{Total: pointsValue, Steps: breakdown})
+}
+
+// calculatePoints calculates the points for a given receipt by running it
+// through the active rule registry.
+func calculatePoints(receipt Receipt) int {
+	total, _ := runRules(receipt)
+	return total
 }
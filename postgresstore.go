@@ -0,0 +1,79 @@
+package main
+
+import (
+	"database/sql"
+	"fmt"
+
+	_ "github.com/lib/pq"
+)
+
+// PostgresStore is a Store backed by a Postgres table, for deployments that
+// run multiple instances behind a load balancer.
+type PostgresStore struct {
+	db *sql.DB
+}
+
+const postgresSchema = `
+CREATE TABLE IF NOT EXISTS receipts (
+	id     TEXT PRIMARY KEY,
+	hash   TEXT UNIQUE NOT NULL,
+	points INTEGER NOT NULL
+);
+`
+
+// NewPostgresStore opens a connection pool to dsn and ensures the receipts
+// table exists.
+func NewPostgresStore(dsn string) (*PostgresStore, error) {
+	db, err := sql.Open("postgres", dsn)
+	if err != nil {
+		return nil, fmt.Errorf("opening postgres store: %w", err)
+	}
+	if err := db.Ping(); err != nil {
+		db.Close()
+		return nil, fmt.Errorf("connecting to postgres store: %w", err)
+	}
+	if _, err := db.Exec(postgresSchema); err != nil {
+		db.Close()
+		return nil, fmt.Errorf("migrating postgres store: %w", err)
+	}
+	return &PostgresStore{db: db}, nil
+}
+
+func (s *PostgresStore) SaveReceipt(id string, receipt Receipt, points int) error {
+	hash, err := hashReceipt(receipt)
+	if err != nil {
+		return err
+	}
+	_, err = s.db.Exec(
+		`INSERT INTO receipts (id, hash, points) VALUES ($1, $2, $3)
+		 ON CONFLICT (id) DO UPDATE SET hash = $2, points = $3`,
+		id, hash, points,
+	)
+	return err
+}
+
+func (s *PostgresStore) GetPoints(id string) (int, bool, error) {
+	var points int
+	err := s.db.QueryRow(`SELECT points FROM receipts WHERE id = $1`, id).Scan(&points)
+	if err == sql.ErrNoRows {
+		return 0, false, nil
+	}
+	if err != nil {
+		return 0, false, err
+	}
+	return points, true, nil
+}
+
+func (s *PostgresStore) LookupByHash(hash string) (string, bool) {
+	var id string
+	err := s.db.QueryRow(`SELECT id FROM receipts WHERE hash = $1`, hash).Scan(&id)
+	if err != nil {
+		return "", false
+	}
+	return id, true
+}
+
+// Close releases the underlying connection pool.
+func (s *PostgresStore) Close() error {
+	return s.db.Close()
+}
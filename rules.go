@@ -0,0 +1,261 @@
+package main
+
+import (
+	"fmt"
+	"log/slog"
+	"math"
+	"os"
+	"path/filepath"
+	"plugin"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Rule is a single scoring rule that can be registered in the rule registry.
+// Built-in rules and rules loaded from plugins both implement this interface.
+// Apply reports the points awarded (delta), whether the rule's condition was
+// satisfied (matched), and a human-readable detail for the trace log.
+type Rule interface {
+	Name() string
+	Apply(Receipt) (delta int, matched bool, detail string)
+}
+
+// RuleResult is the per-rule contribution recorded while scoring a receipt.
+type RuleResult struct {
+	Rule   string `json:"rule"`
+	Delta  int    `json:"delta"`
+	Detail string `json:"detail,omitempty"`
+}
+
+// RulesConfig controls which registered rules run and in what order. An empty
+// Enabled list means "use every registered rule in registration order".
+type RulesConfig struct {
+	Enabled []string `yaml:"enabled"`
+}
+
+var (
+	registryMu  sync.Mutex
+	registry    = map[string]Rule{}
+	ruleOrder   []string // registration order, used when no config overrides it
+	activeRules []string
+)
+
+// RegisterRule adds a rule to the registry, keyed by its Name(). Re-registering
+// an existing name replaces the rule in place without changing its position.
+func RegisterRule(r Rule) {
+	registryMu.Lock()
+	defer registryMu.Unlock()
+	name := r.Name()
+	if _, exists := registry[name]; !exists {
+		ruleOrder = append(ruleOrder, name)
+	}
+	registry[name] = r
+}
+
+func init() {
+	RegisterRule(retailerAlphanumericRule{})
+	RegisterRule(roundDollarRule{})
+	RegisterRule(quarterMultipleRule{})
+	RegisterRule(itemPairRule{})
+	RegisterRule(itemDescriptionRule{})
+	RegisterRule(oddPurchaseDayRule{})
+	RegisterRule(afternoonPurchaseRule{})
+}
+
+// configureRules applies an operator-supplied enable/reorder list on top of
+// the registry's default order. A nil or empty config is a no-op.
+func configureRules(cfg *RulesConfig) {
+	if cfg == nil || len(cfg.Enabled) == 0 {
+		return
+	}
+	registryMu.Lock()
+	defer registryMu.Unlock()
+	activeRules = append([]string(nil), cfg.Enabled...)
+}
+
+// loadRulesConfig reads a YAML RulesConfig from path.
+func loadRulesConfig(path string) (*RulesConfig, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("reading rules config: %w", err)
+	}
+	var cfg RulesConfig
+	if err := yaml.Unmarshal(data, &cfg); err != nil {
+		return nil, fmt.Errorf("parsing rules config: %w", err)
+	}
+	return &cfg, nil
+}
+
+// loadRulePlugins opens every .so file in dir and registers the rules it
+// exports. Each plugin must export a `RulesPlugin` symbol of type
+// `func() []Rule`.
+func loadRulePlugins(dir string) error {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return fmt.Errorf("reading rules dir: %w", err)
+	}
+	for _, entry := range entries {
+		if entry.IsDir() || !strings.HasSuffix(entry.Name(), ".so") {
+			continue
+		}
+		path := filepath.Join(dir, entry.Name())
+		p, err := plugin.Open(path)
+		if err != nil {
+			return fmt.Errorf("opening plugin %s: %w", entry.Name(), err)
+		}
+		sym, err := p.Lookup("RulesPlugin")
+		if err != nil {
+			return fmt.Errorf("plugin %s missing RulesPlugin symbol: %w", entry.Name(), err)
+		}
+		newRules, ok := sym.(func() []Rule)
+		if !ok {
+			return fmt.Errorf("plugin %s: RulesPlugin has the wrong signature", entry.Name())
+		}
+		for _, r := range newRules() {
+			RegisterRule(r)
+		}
+	}
+	return nil
+}
+
+// runRules scores a receipt against the active rule set, executing each rule
+// in turn and recording a trace entry for it. Start/finish of each step is
+// logged at debug level via log/slog rather than printed to stdout.
+func runRules(receipt Receipt) (int, []RuleResult) {
+	registryMu.Lock()
+	order := activeRules
+	if order == nil {
+		order = ruleOrder
+	}
+	order = append([]string(nil), order...)
+	registryMu.Unlock()
+
+	total := 0
+	breakdown := make([]RuleResult, 0, len(order))
+	for _, name := range order {
+		registryMu.Lock()
+		rule, ok := registry[name]
+		registryMu.Unlock()
+		if !ok {
+			continue
+		}
+
+		slog.Debug("rule starting", "rule", name)
+		delta, matched, detail := rule.Apply(receipt)
+		slog.Debug("rule finished", "rule", name, "delta", delta, "matched", matched, "detail", detail)
+
+		total += delta
+		breakdown = append(breakdown, RuleResult{Rule: name, Delta: delta, Detail: detail})
+	}
+	return total, breakdown
+}
+
+var alphanumericRE = regexp.MustCompile("[a-zA-Z0-9]")
+
+// retailerAlphanumericRule awards one point per alphanumeric character in the
+// retailer name.
+type retailerAlphanumericRule struct{}
+
+func (retailerAlphanumericRule) Name() string { return "retailer_alnum" }
+
+func (retailerAlphanumericRule) Apply(receipt Receipt) (int, bool, string) {
+	count := len(alphanumericRE.FindAllString(receipt.Retailer, -1))
+	return count, count > 0, receipt.Retailer
+}
+
+// roundDollarRule awards 50 points when the total has no cents.
+type roundDollarRule struct{}
+
+func (roundDollarRule) Name() string { return "round_dollar" }
+
+func (roundDollarRule) Apply(receipt Receipt) (int, bool, string) {
+	total, err := strconv.ParseFloat(receipt.Total, 64)
+	if err != nil || math.Mod(total, 1.0) != 0 {
+		return 0, false, receipt.Total
+	}
+	return 50, true, receipt.Total
+}
+
+// quarterMultipleRule awards 25 points when the total is a multiple of 0.25.
+type quarterMultipleRule struct{}
+
+func (quarterMultipleRule) Name() string { return "quarter_multiple" }
+
+func (quarterMultipleRule) Apply(receipt Receipt) (int, bool, string) {
+	total, err := strconv.ParseFloat(receipt.Total, 64)
+	if err != nil || math.Mod(total, 0.25) != 0 {
+		return 0, false, receipt.Total
+	}
+	return 25, true, receipt.Total
+}
+
+// itemPairRule awards 5 points for every two items on the receipt.
+type itemPairRule struct{}
+
+func (itemPairRule) Name() string { return "item_pairs" }
+
+func (itemPairRule) Apply(receipt Receipt) (int, bool, string) {
+	pairs := len(receipt.Items) / 2
+	detail := fmt.Sprintf("%d items", len(receipt.Items))
+	return pairs * 5, pairs > 0, detail
+}
+
+// itemDescriptionRule awards points based on the trimmed, alphanumeric-only
+// length of each item's description, for descriptions whose length is a
+// multiple of 3.
+type itemDescriptionRule struct{}
+
+func (itemDescriptionRule) Name() string { return "item_description_length" }
+
+func (itemDescriptionRule) Apply(receipt Receipt) (int, bool, string) {
+	points := 0
+	scored := 0
+	for _, item := range receipt.Items {
+		trimmed := strings.TrimSpace(item.ShortDescription)
+		cleaned := alphanumericRE.FindAllString(trimmed, -1)
+		cleanedLength := len(strings.Join(cleaned, ""))
+		if cleanedLength%3 != 0 {
+			continue
+		}
+		price, err := strconv.ParseFloat(item.Price, 64)
+		if err != nil {
+			continue
+		}
+		points += int(math.Ceil(price * 0.2))
+		scored++
+	}
+	detail := fmt.Sprintf("%d of %d items scored", scored, len(receipt.Items))
+	return points, scored > 0, detail
+}
+
+// oddPurchaseDayRule awards 6 points when the day of the purchase date is odd.
+type oddPurchaseDayRule struct{}
+
+func (oddPurchaseDayRule) Name() string { return "odd_purchase_day" }
+
+func (oddPurchaseDayRule) Apply(receipt Receipt) (int, bool, string) {
+	date, err := time.Parse("2006-01-02", receipt.PurchaseDate)
+	if err != nil || date.Day()%2 == 0 {
+		return 0, false, receipt.PurchaseDate
+	}
+	return 6, true, receipt.PurchaseDate
+}
+
+// afternoonPurchaseRule awards 10 points for purchases made from 2:00pm
+// (inclusive) up to 4:00pm (exclusive).
+type afternoonPurchaseRule struct{}
+
+func (afternoonPurchaseRule) Name() string { return "afternoon_purchase" }
+
+func (afternoonPurchaseRule) Apply(receipt Receipt) (int, bool, string) {
+	purchaseTime, err := time.Parse("15:04", receipt.PurchaseTime)
+	if err != nil || purchaseTime.Hour() < 14 || purchaseTime.Hour() >= 16 {
+		return 0, false, receipt.PurchaseTime
+	}
+	return 10, true, receipt.PurchaseTime
+}
@@ -0,0 +1,116 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+// Verifies that the default rule registry produces the same total as the
+// original hardcoded rule list.
+func TestRunRulesDefaultOrder(t *testing.T) {
+	receipt := Receipt{
+		Retailer:     "Target",
+		PurchaseDate: "2022-01-01",
+		PurchaseTime: "13:01",
+		Items: []Item{
+			{ShortDescription: "Mountain Dew 12PK", Price: "6.49"},
+			{ShortDescription: "Emils Cheese Pizza", Price: "12.25"},
+			{ShortDescription: "Knorr Creamy Chicken", Price: "1.26"},
+			{ShortDescription: "Doritos Nacho Cheese", Price: "3.35"},
+			{ShortDescription: "   Klarbrunn 12-PK 12 FL OZ  ", Price: "12.00"},
+		},
+		Total: "35.35",
+	}
+
+	total, breakdown := runRules(receipt)
+	if total != 26 {
+		t.Errorf("Expected 26 points; got %d", total)
+	}
+	if len(breakdown) != len(ruleOrder) {
+		t.Errorf("Expected a breakdown entry per registered rule; got %d entries", len(breakdown))
+	}
+}
+
+// Verifies that configureRules can narrow which rules run.
+func TestConfigureRulesEnablesSubset(t *testing.T) {
+	defer func() { activeRules = nil }()
+
+	configureRules(&RulesConfig{Enabled: []string{"round_dollar"}})
+
+	receipt := Receipt{Retailer: "Target", Total: "10.00"}
+	total, breakdown := runRules(receipt)
+
+	if total != 50 {
+		t.Errorf("Expected only the round_dollar rule to run; got %d points", total)
+	}
+	if len(breakdown) != 1 || breakdown[0].Rule != "round_dollar" {
+		t.Errorf("Expected a single round_dollar breakdown entry; got %v", breakdown)
+	}
+}
+
+// Verifies that ?explain=1 includes the per-rule breakdown in the response.
+func TestGetPointsHandlerExplain(t *testing.T) {
+	defer func() { activeRules = nil }()
+
+	id := "explain-test"
+	if err := store.SaveReceipt(id, Receipt{Total: "50.00"}, 50); err != nil {
+		t.Fatalf("Failed to seed store: %v", err)
+	}
+	mu.Lock()
+	breakdowns[id] = []RuleResult{{Rule: "round_dollar", Delta: 50}}
+	mu.Unlock()
+
+	req := httptest.NewRequest(http.MethodGet, "/receipts/"+id+"/points?explain=1", nil)
+	w := httptest.NewRecorder()
+	getPointsHandler(w, req)
+
+	resp := w.Result()
+	var result ReceiptPoints
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		t.Fatalf("Failed to decode response: %v", err)
+	}
+	if len(result.Rules) != 1 || result.Rules[0].Rule != "round_dollar" {
+		t.Errorf("Expected explain breakdown to be included; got %v", result.Rules)
+	}
+}
+
+// Verifies that GET /receipts/{id}/trace returns the full rule-execution trace.
+func TestGetTraceHandler(t *testing.T) {
+	id := "trace-test"
+	if err := store.SaveReceipt(id, Receipt{Total: "50.00"}, 50); err != nil {
+		t.Fatalf("Failed to seed store: %v", err)
+	}
+	mu.Lock()
+	breakdowns[id] = []RuleResult{{Rule: "round_dollar", Delta: 50, Detail: "50.00"}}
+	mu.Unlock()
+
+	req := httptest.NewRequest(http.MethodGet, "/receipts/"+id+"/trace", nil)
+	w := httptest.NewRecorder()
+	getTraceHandler(w, req)
+
+	resp := w.Result()
+	var result TraceResponse
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		t.Fatalf("Failed to decode response: %v", err)
+	}
+	if result.Total != 50 {
+		t.Errorf("Expected total 50; got %d", result.Total)
+	}
+	if len(result.Steps) != 1 || result.Steps[0].Rule != "round_dollar" || result.Steps[0].Detail != "50.00" {
+		t.Errorf("Expected a round_dollar trace step with detail; got %v", result.Steps)
+	}
+}
+
+// Verifies that GET /receipts/{id}/trace for an unknown id returns 404.
+func TestGetTraceHandlerNotFound(t *testing.T) {
+	req := httptest.NewRequest(http.MethodGet, "/receipts/nonexistent/trace", nil)
+	w := httptest.NewRecorder()
+	getTraceHandler(w, req)
+
+	resp := w.Result()
+	if resp.StatusCode != http.StatusNotFound {
+		t.Fatalf("Expected status NotFound; got %v", resp.Status)
+	}
+}
@@ -0,0 +1,106 @@
+package main
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"net/http"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+)
+
+// shutdownTimeout bounds how long graceful shutdown waits for in-flight
+// receipt processing to finish before giving up.
+const shutdownTimeout = 15 * time.Second
+
+// ServerOptions configures the address and TLS/mTLS termination for the
+// HTTP server.
+type ServerOptions struct {
+	Addr     string
+	TLSCert  string
+	TLSKey   string
+	ClientCA string // optional CA bundle; when set, mutual TLS is required
+}
+
+// buildServer constructs an *http.Server for handler, configuring TLS (and
+// optional mutual TLS, when opts.ClientCA is set) whenever
+// opts.TLSCert/opts.TLSKey are provided.
+func buildServer(handler http.Handler, opts ServerOptions) (*http.Server, error) {
+	server := &http.Server{
+		Addr:    opts.Addr,
+		Handler: handler,
+	}
+
+	if opts.TLSCert == "" && opts.TLSKey == "" {
+		return server, nil
+	}
+
+	tlsConfig := &tls.Config{}
+	if opts.ClientCA != "" {
+		caCert, err := os.ReadFile(opts.ClientCA)
+		if err != nil {
+			return nil, fmt.Errorf("reading client CA bundle: %w", err)
+		}
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(caCert) {
+			return nil, fmt.Errorf("no certificates found in %s", opts.ClientCA)
+		}
+		tlsConfig.ClientCAs = pool
+		tlsConfig.ClientAuth = tls.RequireAndVerifyClientCert
+	}
+	server.TLSConfig = tlsConfig
+
+	return server, nil
+}
+
+// serve starts server -- over TLS when opts.TLSCert/opts.TLSKey are set,
+// plain HTTP otherwise -- and blocks until it shuts down. SIGINT/SIGTERM
+// trigger a graceful shutdown so in-flight receipt processing completes.
+func serve(server *http.Server, opts ServerOptions) error {
+	errCh := make(chan error, 1)
+	go func() {
+		var err error
+		if opts.TLSCert != "" {
+			err = server.ListenAndServeTLS(opts.TLSCert, opts.TLSKey)
+		} else {
+			err = server.ListenAndServe()
+		}
+		if err == http.ErrServerClosed {
+			err = nil
+		}
+		errCh <- err
+	}()
+
+	sigCh := make(chan os.Signal, 1)
+	signal.Notify(sigCh, syscall.SIGINT, syscall.SIGTERM)
+	defer signal.Stop(sigCh)
+
+	select {
+	case err := <-errCh:
+		return err
+	case <-sigCh:
+		ctx, cancel := context.WithTimeout(context.Background(), shutdownTimeout)
+		defer cancel()
+		if err := server.Shutdown(ctx); err != nil {
+			return err
+		}
+		return <-errCh
+	}
+}
+
+// healthzHandler reports liveness: the process is up and serving requests.
+func healthzHandler(w http.ResponseWriter, r *http.Request) {
+	fmt.Fprintln(w, "ok")
+}
+
+// readyzHandler reports readiness: the configured store is reachable.
+func readyzHandler(w http.ResponseWriter, r *http.Request) {
+	if _, _, err := store.GetPoints("__readyz_probe__"); err != nil {
+		http.Error(w, err.Error(), http.StatusServiceUnavailable)
+		return
+	}
+	fmt.Fprintln(w, "ready")
+}
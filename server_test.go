@@ -0,0 +1,253 @@
+package main
+
+import (
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"math/big"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// Verifies /healthz reports liveness.
+func TestHealthzHandler(t *testing.T) {
+	req := httptest.NewRequest(http.MethodGet, "/healthz", nil)
+	w := httptest.NewRecorder()
+	healthzHandler(w, req)
+
+	if resp := w.Result(); resp.StatusCode != http.StatusOK {
+		t.Fatalf("Expected status OK; got %v", resp.Status)
+	}
+}
+
+// Verifies /readyz reports readiness when the store is reachable.
+func TestReadyzHandler(t *testing.T) {
+	req := httptest.NewRequest(http.MethodGet, "/readyz", nil)
+	w := httptest.NewRecorder()
+	readyzHandler(w, req)
+
+	if resp := w.Result(); resp.StatusCode != http.StatusOK {
+		t.Fatalf("Expected status OK; got %v", resp.Status)
+	}
+}
+
+// Verifies buildServer returns a plain server when no TLS flags are set.
+func TestBuildServerNoTLS(t *testing.T) {
+	server, err := buildServer(http.NewServeMux(), ServerOptions{Addr: ":0"})
+	if err != nil {
+		t.Fatalf("buildServer failed: %v", err)
+	}
+	if server.TLSConfig != nil {
+		t.Errorf("Expected no TLS config when --tls-cert/--tls-key are unset")
+	}
+}
+
+// Verifies buildServer rejects an unreadable client CA bundle.
+func TestBuildServerInvalidClientCA(t *testing.T) {
+	_, err := buildServer(http.NewServeMux(), ServerOptions{
+		Addr:     ":0",
+		TLSCert:  "testdata-does-not-exist.pem",
+		TLSKey:   "testdata-does-not-exist-key.pem",
+		ClientCA: "testdata-does-not-exist-ca.pem",
+	})
+	if err == nil {
+		t.Fatalf("Expected an error for a missing client CA bundle")
+	}
+}
+
+// Verifies that buildServer, given --client-ca, wires ClientCAs/ClientAuth
+// so that the resulting server rejects clients with no certificate and
+// accepts clients presenting a certificate signed by the trusted CA.
+func TestMutualTLSRequiresClientCert(t *testing.T) {
+	caCert, caKey, caPEM := generateTestCA(t)
+	serverCert, serverCertPEM, serverKeyPEM := generateTestCertPEM(t, caCert, caKey, "127.0.0.1")
+	clientCert := generateTestCert(t, caCert, caKey, "test-client")
+
+	pool := x509.NewCertPool()
+	if !pool.AppendCertsFromPEM(caPEM) {
+		t.Fatalf("failed to load test CA into pool")
+	}
+
+	dir := t.TempDir()
+	certPath := filepath.Join(dir, "server-cert.pem")
+	keyPath := filepath.Join(dir, "server-key.pem")
+	caPath := filepath.Join(dir, "ca-cert.pem")
+	writeTestFile(t, certPath, serverCertPEM)
+	writeTestFile(t, keyPath, serverKeyPEM)
+	writeTestFile(t, caPath, caPEM)
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/healthz", healthzHandler)
+
+	server, err := buildServer(mux, ServerOptions{Addr: ":0", TLSCert: certPath, TLSKey: keyPath, ClientCA: caPath})
+	if err != nil {
+		t.Fatalf("buildServer failed: %v", err)
+	}
+	if server.TLSConfig == nil || server.TLSConfig.ClientAuth != tls.RequireAndVerifyClientCert {
+		t.Fatalf("Expected buildServer to require client certs when --client-ca is set")
+	}
+	if server.TLSConfig.ClientCAs == nil {
+		t.Fatalf("Expected buildServer to populate ClientCAs from --client-ca")
+	}
+
+	// buildServer leaves the leaf certificate for ListenAndServeTLS to load
+	// from opts.TLSCert/opts.TLSKey; httptest needs it in the Config itself,
+	// so clone the ClientCAs/ClientAuth wiring buildServer produced and add it.
+	tlsConfig := server.TLSConfig.Clone()
+	tlsConfig.Certificates = []tls.Certificate{serverCert}
+
+	ts := httptest.NewUnstartedServer(mux)
+	ts.TLS = tlsConfig
+	ts.StartTLS()
+	defer ts.Close()
+
+	noCertClient := &http.Client{
+		Transport: &http.Transport{
+			TLSClientConfig: &tls.Config{RootCAs: pool},
+		},
+	}
+	if _, err := noCertClient.Get(ts.URL + "/healthz"); err == nil {
+		t.Fatalf("Expected the handshake to fail without a client certificate")
+	}
+
+	withCertClient := &http.Client{
+		Transport: &http.Transport{
+			TLSClientConfig: &tls.Config{
+				RootCAs:      pool,
+				Certificates: []tls.Certificate{clientCert},
+			},
+		},
+	}
+	resp, err := withCertClient.Get(ts.URL + "/healthz")
+	if err != nil {
+		t.Fatalf("Expected the handshake to succeed with a valid client certificate: %v", err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("Expected status OK; got %v", resp.Status)
+	}
+}
+
+// generateTestCA creates a self-signed CA certificate for use as a trust
+// anchor in TLS tests, returning both the parsed certificate/key and its PEM
+// encoding.
+func generateTestCA(t *testing.T) (*x509.Certificate, *rsa.PrivateKey, []byte) {
+	t.Helper()
+
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatalf("generating CA key: %v", err)
+	}
+
+	template := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "test-ca"},
+		NotBefore:             time.Now(),
+		NotAfter:              time.Now().Add(time.Hour),
+		IsCA:                  true,
+		KeyUsage:              x509.KeyUsageCertSign | x509.KeyUsageDigitalSignature,
+		BasicConstraintsValid: true,
+	}
+
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		t.Fatalf("creating CA certificate: %v", err)
+	}
+	cert, err := x509.ParseCertificate(der)
+	if err != nil {
+		t.Fatalf("parsing CA certificate: %v", err)
+	}
+
+	return cert, key, pemEncodeCert(der)
+}
+
+// generateTestCert creates a leaf certificate signed by the given CA,
+// suitable either as a server or client certificate in TLS tests.
+func generateTestCert(t *testing.T, ca *x509.Certificate, caKey *rsa.PrivateKey, commonName string) tls.Certificate {
+	t.Helper()
+
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatalf("generating leaf key: %v", err)
+	}
+
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(time.Now().UnixNano()),
+		Subject:      pkix.Name{CommonName: commonName},
+		NotBefore:    time.Now(),
+		NotAfter:     time.Now().Add(time.Hour),
+		KeyUsage:     x509.KeyUsageDigitalSignature | x509.KeyUsageKeyEncipherment,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth, x509.ExtKeyUsageClientAuth},
+		DNSNames:     []string{commonName},
+		IPAddresses:  []net.IP{net.ParseIP("127.0.0.1")},
+	}
+
+	der, err := x509.CreateCertificate(rand.Reader, template, ca, &key.PublicKey, caKey)
+	if err != nil {
+		t.Fatalf("creating leaf certificate: %v", err)
+	}
+
+	cert, err := tls.X509KeyPair(pemEncodeCert(der), pemEncodeKey(key))
+	if err != nil {
+		t.Fatalf("building tls.Certificate: %v", err)
+	}
+	return cert
+}
+
+// generateTestCertPEM is generateTestCert plus the PEM encoding of the same
+// certificate/key, for callers that need to write them to disk (as
+// buildServer requires for --tls-cert/--tls-key).
+func generateTestCertPEM(t *testing.T, ca *x509.Certificate, caKey *rsa.PrivateKey, commonName string) (tls.Certificate, []byte, []byte) {
+	t.Helper()
+
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatalf("generating leaf key: %v", err)
+	}
+
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(time.Now().UnixNano()),
+		Subject:      pkix.Name{CommonName: commonName},
+		NotBefore:    time.Now(),
+		NotAfter:     time.Now().Add(time.Hour),
+		KeyUsage:     x509.KeyUsageDigitalSignature | x509.KeyUsageKeyEncipherment,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth, x509.ExtKeyUsageClientAuth},
+		DNSNames:     []string{commonName},
+		IPAddresses:  []net.IP{net.ParseIP("127.0.0.1")},
+	}
+
+	der, err := x509.CreateCertificate(rand.Reader, template, ca, &key.PublicKey, caKey)
+	if err != nil {
+		t.Fatalf("creating leaf certificate: %v", err)
+	}
+
+	certPEM := pemEncodeCert(der)
+	keyPEM := pemEncodeKey(key)
+	cert, err := tls.X509KeyPair(certPEM, keyPEM)
+	if err != nil {
+		t.Fatalf("building tls.Certificate: %v", err)
+	}
+	return cert, certPEM, keyPEM
+}
+
+func writeTestFile(t *testing.T, path string, data []byte) {
+	t.Helper()
+	if err := os.WriteFile(path, data, 0600); err != nil {
+		t.Fatalf("writing %s: %v", path, err)
+	}
+}
+
+func pemEncodeCert(der []byte) []byte {
+	return pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der})
+}
+
+func pemEncodeKey(key *rsa.PrivateKey) []byte {
+	return pem.EncodeToMemory(&pem.Block{Type: "RSA PRIVATE KEY", Bytes: x509.MarshalPKCS1PrivateKey(key)})
+}
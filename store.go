@@ -0,0 +1,83 @@
+package main
+
+import (
+	"fmt"
+	"sync"
+)
+
+// Store persists receipts and their computed points so that a restart --
+// or a second instance behind a load balancer -- doesn't lose data.
+type Store interface {
+	SaveReceipt(id string, receipt Receipt, points int) error
+	GetPoints(id string) (int, bool, error)
+	LookupByHash(hash string) (id string, ok bool)
+}
+
+// StoreOptions configures which Store implementation openStore builds.
+type StoreOptions struct {
+	Kind        string // "memory", "bolt", or "postgres"
+	BoltPath    string // path to the bolt database file, when Kind is "bolt"
+	PostgresDSN string // connection string, when Kind is "postgres"
+}
+
+// openStore builds the Store selected by opts.Kind.
+func openStore(opts StoreOptions) (Store, error) {
+	switch opts.Kind {
+	case "", "memory":
+		return NewMemoryStore(), nil
+	case "bolt":
+		if opts.BoltPath == "" {
+			return nil, fmt.Errorf("--store-path is required for the bolt store")
+		}
+		return NewBoltStore(opts.BoltPath)
+	case "postgres":
+		if opts.PostgresDSN == "" {
+			return nil, fmt.Errorf("--store-dsn is required for the postgres store")
+		}
+		return NewPostgresStore(opts.PostgresDSN)
+	default:
+		return nil, fmt.Errorf("unknown store kind %q", opts.Kind)
+	}
+}
+
+// MemoryStore is the original process-local, mutex-guarded map
+// implementation. Nothing survives a restart.
+type MemoryStore struct {
+	mu     sync.Mutex
+	points map[string]int
+	hashes map[string]string
+}
+
+// NewMemoryStore builds an empty in-memory Store.
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{
+		points: make(map[string]int),
+		hashes: make(map[string]string),
+	}
+}
+
+func (s *MemoryStore) SaveReceipt(id string, receipt Receipt, points int) error {
+	hash, err := hashReceipt(receipt)
+	if err != nil {
+		return err
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.points[id] = points
+	s.hashes[hash] = id
+	return nil
+}
+
+func (s *MemoryStore) GetPoints(id string) (int, bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	p, ok := s.points[id]
+	return p, ok, nil
+}
+
+func (s *MemoryStore) LookupByHash(hash string) (string, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	id, ok := s.hashes[hash]
+	return id, ok
+}
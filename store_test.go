@@ -0,0 +1,65 @@
+package main
+
+import (
+	"path/filepath"
+	"testing"
+)
+
+var sampleStoreReceipt = Receipt{
+	Retailer:     "Target",
+	PurchaseDate: "2022-01-01",
+	PurchaseTime: "13:01",
+	Items:        []Item{{ShortDescription: "Bread", Price: "2.50"}},
+	Total:        "2.50",
+}
+
+// Verifies that MemoryStore round-trips a saved receipt.
+func TestMemoryStoreSaveAndGet(t *testing.T) {
+	s := NewMemoryStore()
+
+	if err := s.SaveReceipt("id-1", sampleStoreReceipt, 42); err != nil {
+		t.Fatalf("SaveReceipt failed: %v", err)
+	}
+
+	pts, ok, err := s.GetPoints("id-1")
+	if err != nil || !ok || pts != 42 {
+		t.Fatalf("expected (42, true, nil); got (%d, %v, %v)", pts, ok, err)
+	}
+
+	hash, err := hashReceipt(sampleStoreReceipt)
+	if err != nil {
+		t.Fatalf("hashReceipt failed: %v", err)
+	}
+	if id, ok := s.LookupByHash(hash); !ok || id != "id-1" {
+		t.Fatalf("expected LookupByHash to find id-1; got (%q, %v)", id, ok)
+	}
+}
+
+// Verifies that data written to a BoltStore survives closing and reopening
+// the database file, exercising the persistence story the Store interface
+// exists for.
+func TestBoltStoreSurvivesReopen(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "receipts.db")
+
+	s, err := NewBoltStore(path)
+	if err != nil {
+		t.Fatalf("NewBoltStore failed: %v", err)
+	}
+	if err := s.SaveReceipt("id-2", sampleStoreReceipt, 17); err != nil {
+		t.Fatalf("SaveReceipt failed: %v", err)
+	}
+	if err := s.Close(); err != nil {
+		t.Fatalf("Close failed: %v", err)
+	}
+
+	reopened, err := NewBoltStore(path)
+	if err != nil {
+		t.Fatalf("reopening bolt store failed: %v", err)
+	}
+	defer reopened.Close()
+
+	pts, ok, err := reopened.GetPoints("id-2")
+	if err != nil || !ok || pts != 17 {
+		t.Fatalf("expected (17, true, nil) after reopen; got (%d, %v, %v)", pts, ok, err)
+	}
+}